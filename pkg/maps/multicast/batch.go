@@ -0,0 +1,365 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	ciliumebpf "github.com/cilium/ebpf"
+
+	"github.com/cilium/cilium/pkg/ebpf"
+)
+
+// batchInsertOpts mirrors the ciliumebpf.UpdateNoExist flag used by the
+// single-key Insert methods: without it, BatchUpdate silently overwrites an
+// existing key's value instead of erroring, which for GroupV4OuterMap would
+// orphan the previous SubscriberV4InnerMap (and every subscriber in it).
+var batchInsertOpts = &ciliumebpf.BatchOptions{ElemFlags: uint64(ciliumebpf.UpdateNoExist)}
+
+// InsertBatch inserts multiple subscribers in as few syscalls as possible,
+// falling back to a per-key Insert loop if the kernel does not support
+// BatchUpdate for this map type. It returns the number of subscribers
+// inserted before any error was encountered.
+func (m *SubscriberV4InnerMap) InsertBatch(subs []*SubscriberV4) (int, error) {
+	if len(subs) == 0 {
+		return 0, nil
+	}
+
+	if !haveBatchUpdateSupport[SubscriberV4Key, SubscriberV4Val](m.Map) {
+		return m.insertBatchFallback(subs)
+	}
+
+	keys := make([]SubscriberV4Key, len(subs))
+	vals := make([]SubscriberV4Val, len(subs))
+	for i, s := range subs {
+		key, err := NewSubscriberV4KeyFromNetIPAddr(s.SAddr)
+		if err != nil {
+			return 0, err
+		}
+
+		var flags SubscriberFlags
+		if s.IsRemote {
+			flags |= SubscriberRemote
+		}
+
+		keys[i] = key
+		vals[i] = SubscriberV4Val{
+			SourceAddr: key.SAddr,
+			Ifindex:    s.Ifindex,
+			Flags:      uint8(flags),
+		}
+	}
+
+	count, err := m.BatchUpdate(keys, vals, batchInsertOpts)
+	if err != nil {
+		return count, fmt.Errorf("failed to batch insert multicast subscribers: %w", err)
+	}
+
+	return count, nil
+}
+
+func (m *SubscriberV4InnerMap) insertBatchFallback(subs []*SubscriberV4) (int, error) {
+	var n int
+	for _, s := range subs {
+		if err := m.Insert(s); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// DeleteBatch deletes multiple subscribers, identified by source address,
+// in as few syscalls as possible, falling back to a per-key Delete loop if
+// the kernel does not support BatchDelete for this map type. It returns the
+// number of subscribers deleted before any error was encountered.
+func (m *SubscriberV4InnerMap) DeleteBatch(sources []netip.Addr) (int, error) {
+	if len(sources) == 0 {
+		return 0, nil
+	}
+
+	if !haveBatchDeleteSupport[SubscriberV4Key, SubscriberV4Val](m.Map) {
+		return m.deleteBatchFallback(sources)
+	}
+
+	keys := make([]SubscriberV4Key, len(sources))
+	for i, src := range sources {
+		key, err := NewSubscriberV4KeyFromNetIPAddr(src)
+		if err != nil {
+			return 0, err
+		}
+		keys[i] = key
+	}
+
+	count, err := m.BatchDelete(keys, nil)
+	if err != nil {
+		return count, fmt.Errorf("failed to batch delete multicast subscribers: %w", err)
+	}
+
+	return count, nil
+}
+
+func (m *SubscriberV4InnerMap) deleteBatchFallback(sources []netip.Addr) (int, error) {
+	var n int
+	for _, src := range sources {
+		if err := m.Delete(src); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// InsertBatch creates multiple multicast groups (each with its own empty
+// SubscriberV4InnerMap) in as few syscalls as possible, falling back to a
+// per-key Insert loop if the kernel does not support BatchUpdate for this
+// map type. It returns the number of groups inserted before any error was
+// encountered.
+func (m *GroupV4OuterMap) InsertBatch(groups []netip.Addr) (int, error) {
+	if len(groups) == 0 {
+		return 0, nil
+	}
+
+	if !haveBatchUpdateSupport[GroupV4Key, GroupV4Val](m.Map) {
+		return m.insertBatchFallback(groups)
+	}
+
+	keys := make([]GroupV4Key, len(groups))
+	vals := make([]GroupV4Val, len(groups))
+	subMaps := make([]*SubscriberV4InnerMap, len(groups))
+
+	for i, group := range groups {
+		key, err := NewGroupV4KeyFromNetIPAddr(group)
+		if err != nil {
+			closeSubscriberMaps(subMaps[:i])
+			return 0, err
+		}
+
+		subMap, err := newSubscriberV4InnerMap(m.logger)
+		if err != nil {
+			closeSubscriberMaps(subMaps[:i])
+			return 0, fmt.Errorf("failed to create SubscriberV4InnerMap: %w", err)
+		}
+
+		keys[i] = key
+		vals[i] = GroupV4Val{FD: uint32(subMap.FD())}
+		subMaps[i] = subMap
+	}
+
+	count, err := m.BatchUpdate(keys, vals, batchInsertOpts)
+	if err != nil {
+		closeSubscriberMaps(subMaps)
+		return count, fmt.Errorf("failed to batch insert multicast groups: %w", err)
+	}
+
+	for _, group := range groups[:count] {
+		m.notify(OpAdd, group)
+	}
+
+	return count, nil
+}
+
+func closeSubscriberMaps(subMaps []*SubscriberV4InnerMap) {
+	for _, subMap := range subMaps {
+		if subMap != nil {
+			subMap.Close()
+		}
+	}
+}
+
+func (m *GroupV4OuterMap) insertBatchFallback(groups []netip.Addr) (int, error) {
+	var n int
+	for _, group := range groups {
+		if err := m.Insert(group); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// DeleteBatch deletes multiple multicast groups in as few syscalls as
+// possible, falling back to a per-key Delete loop if the kernel does not
+// support BatchDelete for this map type. It returns the number of groups
+// deleted before any error was encountered.
+func (m *GroupV4OuterMap) DeleteBatch(groups []netip.Addr) (int, error) {
+	if len(groups) == 0 {
+		return 0, nil
+	}
+
+	if !haveBatchDeleteSupport[GroupV4Key, GroupV4Val](m.Map) {
+		return m.deleteBatchFallback(groups)
+	}
+
+	keys := make([]GroupV4Key, len(groups))
+	for i, group := range groups {
+		key, err := NewGroupV4KeyFromNetIPAddr(group)
+		if err != nil {
+			return 0, err
+		}
+		keys[i] = key
+	}
+
+	count, err := m.BatchDelete(keys, nil)
+	if err != nil {
+		return count, fmt.Errorf("failed to batch delete multicast groups: %w", err)
+	}
+
+	for _, group := range groups[:count] {
+		m.notify(OpDelete, group)
+	}
+
+	return count, nil
+}
+
+func (m *GroupV4OuterMap) deleteBatchFallback(groups []netip.Addr) (int, error) {
+	var n int
+	for _, group := range groups {
+		if err := m.Delete(group); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Reconcile computes the diff between desired and the map's current
+// contents and issues the minimum number of batch insert/delete operations
+// to converge, so that callers (agent controllers resyncing hundreds of
+// subscribers) do not need to re-implement this diff themselves.
+func (m *GroupV4OuterMap) Reconcile(desired map[netip.Addr][]*SubscriberV4) error {
+	current, err := m.List()
+	if err != nil {
+		return fmt.Errorf("failed to list multicast groups: %w", err)
+	}
+
+	newGroups, staleGroups := diffGroups(current, desired)
+
+	if len(newGroups) > 0 {
+		if _, err := m.InsertBatch(newGroups); err != nil {
+			return fmt.Errorf("failed to insert new multicast groups: %w", err)
+		}
+	}
+
+	for group, subs := range desired {
+		if err := m.reconcileSubscribers(group, subs); err != nil {
+			return err
+		}
+	}
+
+	if len(staleGroups) > 0 {
+		if _, err := m.DeleteBatch(staleGroups); err != nil {
+			return fmt.Errorf("failed to delete stale multicast groups: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// diffGroups computes which groups in desired don't yet exist in current
+// (newGroups) and which groups in current are no longer in desired
+// (staleGroups).
+func diffGroups(current []netip.Addr, desired map[netip.Addr][]*SubscriberV4) (newGroups, staleGroups []netip.Addr) {
+	currentSet := make(map[netip.Addr]struct{}, len(current))
+	for _, group := range current {
+		currentSet[group] = struct{}{}
+	}
+
+	for group := range desired {
+		if _, ok := currentSet[group]; !ok {
+			newGroups = append(newGroups, group)
+		}
+	}
+
+	for _, group := range current {
+		if _, ok := desired[group]; !ok {
+			staleGroups = append(staleGroups, group)
+		}
+	}
+
+	return newGroups, staleGroups
+}
+
+func (m *GroupV4OuterMap) reconcileSubscribers(group netip.Addr, desired []*SubscriberV4) error {
+	subMap, err := m.Lookup(group)
+	if err != nil {
+		return fmt.Errorf("failed to look up multicast group %s: %w", group, err)
+	}
+
+	inner, ok := subMap.(*SubscriberV4InnerMap)
+	if !ok {
+		return fmt.Errorf("unexpected SubscriberV4Map implementation %T for group %s", subMap, group)
+	}
+
+	existing, err := inner.List()
+	if err != nil {
+		return fmt.Errorf("failed to list subscribers for group %s: %w", group, err)
+	}
+
+	toInsert, toDelete := diffSubscribers(existing, desired)
+
+	if len(toInsert) > 0 {
+		if _, err := inner.InsertBatch(toInsert); err != nil {
+			return fmt.Errorf("failed to insert subscribers for group %s: %w", group, err)
+		}
+	}
+	if len(toDelete) > 0 {
+		if _, err := inner.DeleteBatch(toDelete); err != nil {
+			return fmt.Errorf("failed to delete subscribers for group %s: %w", group, err)
+		}
+	}
+
+	return nil
+}
+
+// diffSubscribers computes which subscribers in desired don't yet exist in
+// existing (toInsert) and which subscribers in existing, identified by
+// source address, are no longer in desired (toDelete).
+func diffSubscribers(existing, desired []*SubscriberV4) (toInsert []*SubscriberV4, toDelete []netip.Addr) {
+	existingSet := make(map[netip.Addr]struct{}, len(existing))
+	for _, s := range existing {
+		existingSet[s.SAddr] = struct{}{}
+	}
+
+	desiredSet := make(map[netip.Addr]struct{}, len(desired))
+	for _, s := range desired {
+		desiredSet[s.SAddr] = struct{}{}
+		if _, ok := existingSet[s.SAddr]; !ok {
+			toInsert = append(toInsert, s)
+		}
+	}
+
+	for _, s := range existing {
+		if _, ok := desiredSet[s.SAddr]; !ok {
+			toDelete = append(toDelete, s.SAddr)
+		}
+	}
+
+	return toInsert, toDelete
+}
+
+// haveBatchUpdateSupport checks if the kernel supports batch update for the
+// passed map, analogous to haveBatchLookupSupport.
+func haveBatchUpdateSupport[K, V any](m *ebpf.Map) bool {
+	keys := make([]K, 0)
+	vals := make([]V, 0)
+	_, err := m.BatchUpdate(keys, vals, nil)
+	if err != nil && errors.Is(err, ciliumebpf.ErrNotSupported) {
+		return false
+	}
+	return true
+}
+
+// haveBatchDeleteSupport checks if the kernel supports batch delete for the
+// passed map, analogous to haveBatchLookupSupport.
+func haveBatchDeleteSupport[K, V any](m *ebpf.Map) bool {
+	keys := make([]K, 0)
+	_, err := m.BatchDelete(keys, nil)
+	if err != nil && errors.Is(err, ciliumebpf.ErrNotSupported) {
+		return false
+	}
+	return true
+}
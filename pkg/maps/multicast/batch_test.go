@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func addr(s string) netip.Addr {
+	return netip.MustParseAddr(s)
+}
+
+func TestDiffGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   []netip.Addr
+		desired   map[netip.Addr][]*SubscriberV4
+		wantNew   []netip.Addr
+		wantStale []netip.Addr
+	}{
+		{
+			name:    "no-op when current matches desired",
+			current: []netip.Addr{addr("239.1.1.1")},
+			desired: map[netip.Addr][]*SubscriberV4{addr("239.1.1.1"): nil},
+		},
+		{
+			name:    "new group added",
+			current: nil,
+			desired: map[netip.Addr][]*SubscriberV4{addr("239.1.1.1"): nil},
+			wantNew: []netip.Addr{addr("239.1.1.1")},
+		},
+		{
+			name:      "stale group removed",
+			current:   []netip.Addr{addr("239.1.1.1")},
+			desired:   map[netip.Addr][]*SubscriberV4{},
+			wantStale: []netip.Addr{addr("239.1.1.1")},
+		},
+		{
+			name:      "one new, one stale, one unchanged",
+			current:   []netip.Addr{addr("239.1.1.1"), addr("239.1.1.2")},
+			desired:   map[netip.Addr][]*SubscriberV4{addr("239.1.1.1"): nil, addr("239.1.1.3"): nil},
+			wantNew:   []netip.Addr{addr("239.1.1.3")},
+			wantStale: []netip.Addr{addr("239.1.1.2")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotNew, gotStale := diffGroups(tt.current, tt.desired)
+			if !sameAddrSet(gotNew, tt.wantNew) {
+				t.Fatalf("diffGroups() newGroups = %v, want %v", gotNew, tt.wantNew)
+			}
+			if !sameAddrSet(gotStale, tt.wantStale) {
+				t.Fatalf("diffGroups() staleGroups = %v, want %v", gotStale, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestDiffSubscribers(t *testing.T) {
+	sub := func(s string) *SubscriberV4 {
+		return &SubscriberV4{SAddr: addr(s)}
+	}
+
+	tests := []struct {
+		name          string
+		existing      []*SubscriberV4
+		desired       []*SubscriberV4
+		wantInsertLen int
+		wantDelete    []netip.Addr
+	}{
+		{
+			name:     "no-op when existing matches desired",
+			existing: []*SubscriberV4{sub("10.0.0.1")},
+			desired:  []*SubscriberV4{sub("10.0.0.1")},
+		},
+		{
+			name:          "new subscriber inserted",
+			existing:      nil,
+			desired:       []*SubscriberV4{sub("10.0.0.1")},
+			wantInsertLen: 1,
+		},
+		{
+			name:       "stale subscriber deleted",
+			existing:   []*SubscriberV4{sub("10.0.0.1")},
+			desired:    nil,
+			wantDelete: []netip.Addr{addr("10.0.0.1")},
+		},
+		{
+			name:          "one added, one removed, one unchanged",
+			existing:      []*SubscriberV4{sub("10.0.0.1"), sub("10.0.0.2")},
+			desired:       []*SubscriberV4{sub("10.0.0.1"), sub("10.0.0.3")},
+			wantInsertLen: 1,
+			wantDelete:    []netip.Addr{addr("10.0.0.2")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toInsert, toDelete := diffSubscribers(tt.existing, tt.desired)
+			if len(toInsert) != tt.wantInsertLen {
+				t.Fatalf("diffSubscribers() toInsert = %v, want length %d", toInsert, tt.wantInsertLen)
+			}
+			gotDelete := make([]netip.Addr, len(toDelete))
+			copy(gotDelete, toDelete)
+			if !sameAddrSet(gotDelete, tt.wantDelete) {
+				t.Fatalf("diffSubscribers() toDelete = %v, want %v", toDelete, tt.wantDelete)
+			}
+		})
+	}
+}
+
+// sameAddrSet reports whether got and want contain the same addresses,
+// ignoring order (diffGroups/diffSubscribers iterate maps, so no ordering
+// guarantee is provided or expected).
+func sameAddrSet(got, want []netip.Addr) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	g, w := slices.Clone(got), slices.Clone(want)
+	slices.SortFunc(g, netip.Addr.Compare)
+	slices.SortFunc(w, netip.Addr.Compare)
+	return slices.Equal(g, w)
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import "github.com/spf13/pflag"
+
+const (
+	// MulticastEnabledName is the flag name for enabling IPv4 BPF multicast
+	// group support.
+	MulticastEnabledName = "enable-multicast"
+	// EnableIPv6MulticastName is the flag name for enabling IPv6 BPF
+	// multicast group support. It has no effect unless MulticastEnabled is
+	// also set.
+	EnableIPv6MulticastName = "enable-ipv6-multicast"
+)
+
+// Config holds the user configuration for the multicast subsystem.
+type Config struct {
+	// MulticastEnabled enables the BPF multicast group and subscriber maps.
+	MulticastEnabled bool `mapstructure:"enable-multicast"`
+
+	// EnableIPv6Multicast additionally enables the IPv6 multicast group and
+	// subscriber maps. Requires MulticastEnabled.
+	EnableIPv6Multicast bool `mapstructure:"enable-ipv6-multicast"`
+}
+
+// Flags implements the cell.Flagger interface.
+func (def Config) Flags(flags *pflag.FlagSet) {
+	flags.Bool(MulticastEnabledName, def.MulticastEnabled, "Enable Cilium multicast group and subscriber maps")
+	flags.Bool(EnableIPv6MulticastName, def.EnableIPv6Multicast, "Enable Cilium IPv6 multicast group and subscriber maps")
+}
@@ -0,0 +1,468 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"unsafe"
+
+	ciliumebpf "github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/hive/cell"
+
+	"github.com/cilium/cilium/pkg/bpf"
+	"github.com/cilium/cilium/pkg/datapath/linux/config/defines"
+	"github.com/cilium/cilium/pkg/datapath/linux/probes"
+	"github.com/cilium/cilium/pkg/ebpf"
+)
+
+// compile time checks
+var _ GroupV6Map = (*GroupV6OuterMap)(nil)
+var _ SubscriberV6Map = (*SubscriberV6InnerMap)(nil)
+
+const (
+	// Pinned outer map name which signals the existence of an IPv6
+	// multicast group in the control plane.
+	GroupOuter6MapName = "cilium_mcast_group_outer_v6_map"
+)
+
+// GroupV6Map provides an interface between the control and data plane,
+// enabling the creation, deletion, and querying of IPv6 multicast groups
+// and subscribers.
+type GroupV6Map interface {
+	Lookup(multicastAddr netip.Addr) (SubscriberV6Map, error)
+	Insert(multicastAddr netip.Addr) error
+	Delete(multicastAddr netip.Addr) error
+	List() ([]netip.Addr, error)
+}
+
+// ParamsOutV6 are the parameters provided to the Hive and is the return
+// argument for NewGroupV6Map. It cannot reuse ParamsOut: that struct's
+// embedded bpf.MapOut[GroupV4Map] is a distinct generic instantiation from
+// bpf.MapOut[GroupV6Map], and the two are not assignable.
+type ParamsOutV6 struct {
+	cell.Out
+	bpf.MapOut[GroupV6Map]
+	defines.NodeOut
+}
+
+// GroupV6OuterMap outer map keyed by GroupV6Key multicast group
+// addresses.
+type GroupV6OuterMap struct {
+	*ebpf.Map
+
+	// batchLookupSupported indicates if the kernel supports batch lookup.
+	batchLookupSupported bool
+	logger               *slog.Logger
+}
+
+func NewGroupV6OuterMap(logger *slog.Logger, name string) *GroupV6OuterMap {
+	innerMap := newSubscriberV6InnerMapSpec()
+	m := ebpf.NewMap(logger, &ebpf.MapSpec{
+		Name:       name,
+		Type:       ebpf.HashOfMaps,
+		KeySize:    uint32(unsafe.Sizeof(GroupV6Key{})),
+		ValueSize:  uint32(unsafe.Sizeof(GroupV6Val{})),
+		MaxEntries: uint32(MaxGroups),
+		InnerMap:   innerMap,
+		Pinning:    ebpf.PinByName,
+	})
+
+	return &GroupV6OuterMap{logger: logger, Map: m}
+}
+
+// NewGroupV6Map creates a new GroupV6Map
+// and provides it to the hive dependency injection graph.
+//
+// Other subsystems can depend on the "multicast.GroupV6Map" type to obtain
+// a handle to the IPv6 datapath interface.
+func NewGroupV6Map(in ParamsIn) ParamsOutV6 {
+	out := ParamsOutV6{}
+
+	if !in.MulticastEnabled || !in.EnableIPv6Multicast {
+		return out
+	}
+
+	// must have "bpf_map_for_each_elem" helper available, if not, don't
+	// initialize the map, dependent code should be checking if their map
+	// dependency is nil or not.
+	if probes.HaveProgramHelper(in.Logger, ciliumebpf.SchedCLS, asm.FnForEachMapElem) != nil {
+		in.Logger.Error("Disabled support for BPF IPv6 Multicast due to missing kernel support (Linux 5.13 or later)")
+		return out
+	}
+
+	out.NodeDefines["ENABLE_MULTICAST_V6"] = "1"
+
+	groupMap := NewGroupV6OuterMap(in.Logger, GroupOuter6MapName)
+
+	out.MapOut = bpf.NewMapOut((GroupV6Map(groupMap)))
+
+	in.Lifecycle.Append(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			err := groupMap.OpenOrCreate()
+			if err != nil {
+				return err
+			}
+			groupMap.batchLookupSupported = haveBatchLookupSupport[GroupV6Key, GroupV6Val](groupMap.Map)
+			return nil
+		},
+		OnStop: func(cell.HookContext) error {
+			return groupMap.Close()
+		},
+	})
+
+	return out
+}
+
+func (m GroupV6OuterMap) Insert(group netip.Addr) error {
+	key, err := NewGroupV6KeyFromNetIPAddr(group)
+	if err != nil {
+		return err
+	}
+
+	subMap, err := newSubscriberV6InnerMap(m.logger)
+	if err != nil {
+		return fmt.Errorf("failed to create SubscriberV6InnerMap: %w", err)
+	}
+
+	val := GroupV6Val{
+		FD: uint32(subMap.FD()),
+	}
+
+	err = m.Update(key, val, ciliumebpf.UpdateNoExist)
+	if err != nil {
+		subMap.Close()
+		return fmt.Errorf("failed to create new multicast group entry: %w", err)
+	}
+
+	return nil
+}
+
+func (m GroupV6OuterMap) Lookup(group netip.Addr) (SubscriberV6Map, error) {
+	var val GroupV6Val
+
+	key, err := NewGroupV6KeyFromNetIPAddr(group)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Map.Lookup(key.Group, &val)
+	if errors.Is(err, ebpf.ErrKeyNotExist) {
+		return nil, fmt.Errorf("multicast group %s does not exist: %w", group.String(), err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query for multicast group: %w", err)
+	}
+
+	var subMap *ebpf.Map
+	subMap, err = ebpf.MapFromID(m.logger, int(val.FD))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert SubscriberV6InnerMap FD to *ebpf.Map: %w", err)
+	}
+
+	return &SubscriberV6InnerMap{subMap}, nil
+}
+
+func (m GroupV6OuterMap) Delete(group netip.Addr) error {
+	key, err := NewGroupV6KeyFromNetIPAddr(group)
+	if err != nil {
+		return err
+	}
+	return m.Map.Delete(key)
+}
+
+// List returns a list of all multicast groups in the map. Batch lookup is used to get the groups if supported.
+// Batch lookup is supported in kernel version 5.19 and later for map.HashOfMaps
+func (m GroupV6OuterMap) List() ([]netip.Addr, error) {
+	if m.batchLookupSupported {
+		return m.ListBatch()
+	}
+	return m.ListIterator()
+}
+
+// ListIterator is a iterator version of List. It is used when the map does not support batch lookup.
+func (m GroupV6OuterMap) ListIterator() ([]netip.Addr, error) {
+	var (
+		key GroupV6Key
+		val GroupV6Val
+		out = make([]netip.Addr, 0, MaxGroups)
+	)
+
+	iter := m.Iterate()
+	for iter.Next(&key, &val) {
+		ip, ok := key.ToNetIPAddr()
+		if !ok {
+			return out, fmt.Errorf("failed to convert key to netip.Addr")
+		}
+		out = append(out, ip)
+	}
+
+	return out, iter.Err()
+}
+
+// ListBatch is a batched version of List. It is used when the map supports batch lookup.
+func (m GroupV6OuterMap) ListBatch() ([]netip.Addr, error) {
+	var (
+		keys = make([]GroupV6Key, MaxGroups)
+		vals = make([]GroupV6Val, MaxGroups)
+		out  = make([]netip.Addr, 0, MaxGroups)
+	)
+
+	var cursor ciliumebpf.MapBatchCursor
+	count := 0
+	for {
+		c, batchErr := m.BatchLookup(&cursor, keys, vals, nil)
+		count += c
+		if batchErr != nil {
+			if errors.Is(batchErr, ebpf.ErrKeyNotExist) {
+				break
+			}
+			return nil, batchErr
+		}
+	}
+
+	for i := 0; i < len(keys) && i < count; i++ {
+		group, ok := keys[i].ToNetIPAddr()
+		if !ok {
+			return nil, fmt.Errorf("failed to convert GroupV6Key.Group to netip.Addr")
+		}
+		out = append(out, group)
+	}
+
+	return out, nil
+}
+
+// GroupV6Key is the key for a GroupV6OuterMap
+// It is an IPv6 multicast group address in big endian format.
+type GroupV6Key struct {
+	Group [16]byte
+}
+
+func NewGroupV6KeyFromNetIPAddr(ip netip.Addr) (out GroupV6Key, err error) {
+	if !ip.Is6() || ip.Is4In6() || !ip.IsMulticast() {
+		return out, fmt.Errorf("ip must be an IPv6 multicast address")
+	}
+	out.Group = ip.As16()
+	return out, nil
+}
+
+func (k GroupV6Key) ToNetIPAddr() (netip.Addr, bool) {
+	return netip.AddrFromSlice(k.Group[:])
+}
+
+// GroupV6Val is the value of a GroupV6OuterMap.
+// It is a file descriptor for an inner SubscriberV6InnerMap.
+type GroupV6Val struct {
+	FD uint32
+}
+
+func OpenGroupV6OuterMap(logger *slog.Logger, name string) (*GroupV6OuterMap, error) {
+	m, err := ebpf.LoadRegisterMap(logger, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GroupV6OuterMap{
+		Map:                  m,
+		batchLookupSupported: haveBatchLookupSupport[GroupV6Key, GroupV6Val](m),
+	}, nil
+}
+
+// SubscriberV6Map provides an interface between the control and data plane,
+// enabling the creation, deletion, and querying of IPv6 multicast subscribers
+// within a multicast group.
+type SubscriberV6Map interface {
+	Insert(*SubscriberV6) error
+	Lookup(Src netip.Addr) (*SubscriberV6, error)
+	Delete(Src netip.Addr) error
+	List() ([]*SubscriberV6, error)
+}
+
+// SubscriberV6 is an IPv6 multicast subscriber.
+type SubscriberV6 struct {
+	// Source address of subscriber in big endian format
+	SAddr netip.Addr
+	// Interface ID of subscriber, may be a tunnel interface if subscriber
+	// is remote.
+	Ifindex uint32
+	// Specifies if the subscriber is remote or local
+	IsRemote bool
+}
+
+// SubscriberV6InnerMap is the inner map of a GroupV6OuterMap outer
+// map.
+//
+// This map inventories all subscribers, both local and remote, for a given
+// IPv6 multicast group.
+type SubscriberV6InnerMap struct {
+	*ebpf.Map
+}
+
+func newSubscriberV6InnerMap(logger *slog.Logger) (*SubscriberV6InnerMap, error) {
+	spec := newSubscriberV6InnerMapSpec()
+
+	m := ebpf.NewMap(logger, spec)
+	if err := m.OpenOrCreate(); err != nil {
+		return nil, err
+	}
+
+	return &SubscriberV6InnerMap{m}, nil
+}
+
+// SubscriberV6Key is the IPv6 source address of the multicast subscriber
+// in big endian format.
+type SubscriberV6Key struct {
+	SAddr [16]byte
+}
+
+func NewSubscriberV6KeyFromNetIPAddr(ip netip.Addr) (out SubscriberV6Key, err error) {
+	if !ip.Is6() || ip.Is4In6() {
+		return out, fmt.Errorf("ip must be IPv6")
+	}
+	out.SAddr = ip.As16()
+	return out, nil
+}
+
+func (k SubscriberV6Key) ToNetIPAddr() (netip.Addr, bool) {
+	return netip.AddrFromSlice(k.SAddr[:])
+}
+
+// SubscriberV6Val is a discrete subscriber value of an IPv6 multicast group
+// map.
+type SubscriberV6Val struct {
+	// Source address of subscriber in big endian format
+	SourceAddr [16]byte `align:"saddr"`
+	// Interface ID of subscriber, may be a tunnel interface if subscriber
+	// is remote.
+	Ifindex uint32 `align:"ifindex"`
+	// reserved
+	Pad1 uint16 `align:"pad1"`
+	// reserved
+	Pad2 uint8 `align:"pad2"`
+	// SubscriberFlags flag bits which further a subscriber's
+	// characteristics.
+	Flags uint8 `align:"flags"`
+}
+
+func (v *SubscriberV6Val) ToSubscriberV6() (*SubscriberV6, error) {
+	saddr, ok := SubscriberV6Key{SAddr: v.SourceAddr}.ToNetIPAddr()
+	if !ok {
+		return nil, fmt.Errorf("failed to convert SubscriberV6Val.SAddr to netip.Addr")
+	}
+	sub := &SubscriberV6{
+		SAddr:   saddr,
+		Ifindex: v.Ifindex,
+	}
+	if v.Flags != 0 {
+		// only one possibility right now
+		sub.IsRemote = true
+	}
+	return sub, nil
+}
+
+func newSubscriberV6InnerMapSpec() *ebpf.MapSpec {
+	flags := bpf.GetMapMemoryFlags(ebpf.Hash)
+	return &ebpf.MapSpec{
+		Name:       "cilium_mcast_subscriber_v6_inner",
+		Type:       ebpf.Hash,
+		KeySize:    uint32(unsafe.Sizeof(SubscriberV6Key{})),
+		ValueSize:  uint32(unsafe.Sizeof(SubscriberV6Val{})),
+		MaxEntries: uint32(MaxSubscribers),
+		Flags:      flags,
+	}
+}
+
+func (m SubscriberV6InnerMap) Insert(s *SubscriberV6) error {
+	key, err := NewSubscriberV6KeyFromNetIPAddr(s.SAddr)
+	if err != nil {
+		return err
+	}
+
+	var flags SubscriberFlags = 0
+	switch {
+	case s.IsRemote:
+		flags |= SubscriberRemote
+	}
+
+	val := SubscriberV6Val{
+		SourceAddr: key.SAddr,
+		Ifindex:    s.Ifindex,
+		Flags:      uint8(flags),
+	}
+
+	err = m.Update(key.SAddr, val, ciliumebpf.UpdateNoExist)
+	if err != nil {
+		return fmt.Errorf("failed to insert multicast subscriber: %w", err)
+	}
+
+	return nil
+}
+
+func (m SubscriberV6InnerMap) Lookup(Src netip.Addr) (*SubscriberV6, error) {
+	val := SubscriberV6Val{}
+
+	key, err := NewSubscriberV6KeyFromNetIPAddr(Src)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Map.Lookup(key.SAddr, &val)
+	if errors.Is(err, ebpf.ErrKeyNotExist) {
+		return nil, fmt.Errorf("no subscriber with source address %s: %w", Src.String(), err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup subscriber %s: %w", Src.String(), err)
+	}
+
+	sub, err := val.ToSubscriberV6()
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (m SubscriberV6InnerMap) Delete(Src netip.Addr) error {
+	key, err := NewSubscriberV6KeyFromNetIPAddr(Src)
+	if err != nil {
+		return err
+	}
+	return m.Map.Delete(key)
+}
+
+// List returns a list of all subscribers in the map. Batch lookup is used to get the subscribers.
+// Minimum kernel version required for multicast is 5.13, in which batch lookup for map.Hash is supported.
+func (m SubscriberV6InnerMap) List() ([]*SubscriberV6, error) {
+	var (
+		keys = make([]SubscriberV6Key, MaxSubscribers)
+		vals = make([]SubscriberV6Val, MaxSubscribers)
+		out  = make([]*SubscriberV6, 0, MaxSubscribers)
+	)
+
+	var cursor ciliumebpf.MapBatchCursor
+	count := 0
+	for {
+		c, batchErr := m.BatchLookup(&cursor, keys, vals, nil)
+		count += c
+		if batchErr != nil {
+			if errors.Is(batchErr, ebpf.ErrKeyNotExist) {
+				break
+			}
+			return nil, batchErr
+		}
+	}
+
+	for i := 0; i < len(vals) && i < count; i++ {
+		sub, err := vals[i].ToSubscriberV6()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sub)
+	}
+
+	return out, nil
+}
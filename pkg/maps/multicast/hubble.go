@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/hive/cell"
+)
+
+// ReplicationFlow is a sampled observation of multicast packet replication
+// for a single group.
+type ReplicationFlow struct {
+	GroupAddr netip.Addr
+	// Packets/Bytes are the cumulative GroupStats counters at the time of
+	// sampling, not deltas.
+	Packets uint64
+	Bytes   uint64
+}
+
+// FlowSink receives sampled ReplicationFlow observations. Hubble's monitor
+// consumer implements this to emit FlowType_MULTICAST_REPLICATION events;
+// this package does not import Hubble directly to avoid a dependency
+// cycle.
+type FlowSink interface {
+	ObserveMulticastReplication(ReplicationFlow)
+}
+
+const (
+	// defaultStatsSamplePeriod is how often GroupStats are polled per
+	// known group.
+	defaultStatsSamplePeriod = time.Second
+	// defaultSampleRate emits one ReplicationFlow per N replicated
+	// packets observed, bounding Hubble overhead under high multicast
+	// fan-out.
+	defaultSampleRate = 100
+)
+
+// StatsSamplerCell provides the StatsSampler singleton to the hive
+// dependency injection graph.
+var StatsSamplerCell = cell.Module(
+	"multicast-stats-sampler",
+	"Samples multicast forwarding stats into Hubble flow events",
+
+	cell.Provide(NewStatsSampler),
+)
+
+// StatsSamplerParams are the parameters provided by the Hive for
+// constructing a StatsSampler.
+type StatsSamplerParams struct {
+	cell.In
+
+	Lifecycle cell.Lifecycle
+	Logger    *slog.Logger
+	Groups    GroupV4Map `optional:"true"`
+	Sink      FlowSink   `optional:"true"`
+}
+
+// StatsSampler polls GroupV4Map.Stats for every known group and forwards a
+// sampled subset of replication activity to a FlowSink, so operators can
+// observe multicast forwarding end-to-end through Hubble without every
+// replicated packet needing to cross into userspace.
+type StatsSampler struct {
+	logger     *slog.Logger
+	groups     GroupV4Map
+	sink       FlowSink
+	period     time.Duration
+	sampleRate uint64
+	cancel     context.CancelFunc
+
+	last map[netip.Addr]GroupStats
+	seq  atomic.Uint64
+}
+
+// NewStatsSampler constructs a StatsSampler and registers its run loop with
+// the hive lifecycle. It is a no-op if multicast is disabled or no FlowSink
+// is available.
+func NewStatsSampler(p StatsSamplerParams) *StatsSampler {
+	s := &StatsSampler{
+		logger:     p.Logger,
+		groups:     p.Groups,
+		sink:       p.Sink,
+		period:     defaultStatsSamplePeriod,
+		sampleRate: defaultSampleRate,
+		last:       make(map[netip.Addr]GroupStats),
+	}
+
+	if p.Groups == nil || p.Sink == nil {
+		return s
+	}
+
+	p.Lifecycle.Append(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.cancel = cancel
+			go s.run(ctx)
+			return nil
+		},
+		OnStop: func(cell.HookContext) error {
+			if s.cancel != nil {
+				s.cancel()
+			}
+			return nil
+		},
+	})
+
+	return s
+}
+
+func (s *StatsSampler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+// sample compares the current GroupStats against the last observed
+// snapshot for each group and forwards every sampleRate-th replicated
+// packet in the delta to the FlowSink.
+func (s *StatsSampler) sample() {
+	groups, err := s.groups.List()
+	if err != nil {
+		s.logger.Warn("multicast stats sampler failed to list groups", "error", err)
+		return
+	}
+
+	seen := make(map[netip.Addr]struct{}, len(groups))
+
+	for _, group := range groups {
+		seen[group] = struct{}{}
+
+		stats, err := s.groups.Stats(group)
+		if err != nil {
+			s.logger.Debug("multicast stats sampler failed to read group stats", "group", group, "error", err)
+			continue
+		}
+
+		prev, observed := s.last[group]
+		s.last[group] = stats
+		if !observed {
+			// First observation of this group: establish a baseline
+			// instead of treating its entire historical counter as a
+			// delta that happened in this tick.
+			continue
+		}
+
+		if stats.Replications < prev.Replications {
+			// The counter went backwards: the group was deleted and
+			// recreated (or the stats map itself was), so prev is stale.
+			// Treat stats as a new baseline rather than underflowing the
+			// subtraction below into a huge uint64.
+			continue
+		}
+
+		deltaReplications := stats.Replications - prev.Replications
+		if deltaReplications == 0 {
+			continue
+		}
+
+		// Advance seq by the full delta in one step and emit a single
+		// sample for each sampleRate boundary crossed, rather than
+		// looping once per replicated packet.
+		before := s.seq.Add(deltaReplications) - deltaReplications
+		samples := (before+deltaReplications)/s.sampleRate - before/s.sampleRate
+		for i := uint64(0); i < samples; i++ {
+			s.sink.ObserveMulticastReplication(ReplicationFlow{
+				GroupAddr: group,
+				Packets:   stats.Packets,
+				Bytes:     stats.Bytes,
+			})
+		}
+	}
+
+	for group := range s.last {
+		if _, ok := seen[group]; !ok {
+			delete(s.last, group)
+		}
+	}
+}
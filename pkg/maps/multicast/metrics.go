@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/cilium/hive/cell"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "cilium"
+
+// MetricsCell registers the multicast Prometheus collectors and, if a
+// GroupV4Map is available, decorates it so that Insert/Delete/Lookup are
+// instrumented without the map implementation needing to know about
+// metrics itself.
+var MetricsCell = cell.Module(
+	"multicast-metrics",
+	"Prometheus metrics for the multicast subsystem",
+
+	cell.Provide(NewMetrics),
+	cell.Decorate(decorateGroupV4Map),
+)
+
+// Metrics holds the Prometheus collectors for the multicast subsystem.
+type Metrics struct {
+	// Operations counts Insert/Delete/Lookup calls, partitioned by
+	// operation and result.
+	Operations *prometheus.CounterVec
+}
+
+// NewMetrics creates the multicast Prometheus collectors.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Operations: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "multicast_map_operations_total",
+			Help:      "Number of multicast map operations, partitioned by operation and result",
+		}, []string{"op", "result"}),
+	}
+}
+
+// decorateGroupV4Map wraps groups, if present, with an instrumented
+// GroupV4Map and registers a scrape-time collector for
+// cilium_multicast_groups/cilium_multicast_subscribers.
+func decorateGroupV4Map(m *Metrics, groups GroupV4Map) GroupV4Map {
+	if groups == nil {
+		return groups
+	}
+
+	if err := prometheus.Register(newGroupCollector(groups)); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			panic(err)
+		}
+	}
+
+	return &instrumentedGroupV4Map{inner: groups, metrics: m}
+}
+
+// groupCollector implements prometheus.Collector, computing
+// cilium_multicast_groups and cilium_multicast_subscribers from a fresh
+// List() snapshot on every scrape rather than maintaining counters that can
+// drift from the map's actual contents.
+type groupCollector struct {
+	groups          GroupV4Map
+	groupsDesc      *prometheus.Desc
+	subscribersDesc *prometheus.Desc
+}
+
+func newGroupCollector(groups GroupV4Map) *groupCollector {
+	return &groupCollector{
+		groups: groups,
+		groupsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "multicast_groups"),
+			"Number of multicast groups known to the local node",
+			nil, nil,
+		),
+		subscribersDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "multicast_subscribers"),
+			"Number of subscribers in a multicast group known to the local node",
+			[]string{"group"}, nil,
+		),
+	}
+}
+
+func (c *groupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.groupsDesc
+	ch <- c.subscribersDesc
+}
+
+func (c *groupCollector) Collect(ch chan<- prometheus.Metric) {
+	groups, err := c.groups.List()
+	if err != nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.groupsDesc, prometheus.GaugeValue, float64(len(groups)))
+
+	for _, group := range groups {
+		subMap, err := c.groups.Lookup(group)
+		if err != nil {
+			continue
+		}
+
+		subs, err := subMap.List()
+		if err != nil {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.subscribersDesc, prometheus.GaugeValue, float64(len(subs)), group.String())
+	}
+}
+
+// instrumentedGroupV4Map wraps a GroupV4Map, recording Operations for every
+// Insert/Delete/Lookup call before delegating to inner.
+type instrumentedGroupV4Map struct {
+	inner   GroupV4Map
+	metrics *Metrics
+}
+
+var _ GroupV4Map = (*instrumentedGroupV4Map)(nil)
+
+func (m *instrumentedGroupV4Map) record(op string, err error) {
+	m.metrics.Operations.WithLabelValues(op, resultLabel(err)).Inc()
+}
+
+func (m *instrumentedGroupV4Map) Insert(group netip.Addr) error {
+	err := m.inner.Insert(group)
+	m.record("insert", err)
+	return err
+}
+
+func (m *instrumentedGroupV4Map) Delete(group netip.Addr) error {
+	err := m.inner.Delete(group)
+	m.record("delete", err)
+	return err
+}
+
+func (m *instrumentedGroupV4Map) Lookup(group netip.Addr) (SubscriberV4Map, error) {
+	subMap, err := m.inner.Lookup(group)
+	m.record("lookup", err)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedSubscriberV4Map{inner: subMap, metrics: m.metrics}, nil
+}
+
+func (m *instrumentedGroupV4Map) List() ([]netip.Addr, error) {
+	return m.inner.List()
+}
+
+func (m *instrumentedGroupV4Map) OnChange(hook GroupHookFunc) {
+	m.inner.OnChange(hook)
+}
+
+func (m *instrumentedGroupV4Map) Reconcile(desired map[netip.Addr][]*SubscriberV4) error {
+	return m.inner.Reconcile(desired)
+}
+
+func (m *instrumentedGroupV4Map) Stats(group netip.Addr) (GroupStats, error) {
+	return m.inner.Stats(group)
+}
+
+// instrumentedSubscriberV4Map wraps a SubscriberV4Map, recording Operations
+// for every Insert/Delete/Lookup call before delegating to inner.
+type instrumentedSubscriberV4Map struct {
+	inner   SubscriberV4Map
+	metrics *Metrics
+}
+
+var _ SubscriberV4Map = (*instrumentedSubscriberV4Map)(nil)
+
+func (m *instrumentedSubscriberV4Map) Insert(s *SubscriberV4) error {
+	err := m.inner.Insert(s)
+	m.metrics.Operations.WithLabelValues("insert", resultLabel(err)).Inc()
+	return err
+}
+
+func (m *instrumentedSubscriberV4Map) Lookup(src netip.Addr) (*SubscriberV4, error) {
+	sub, err := m.inner.Lookup(src)
+	m.metrics.Operations.WithLabelValues("lookup", resultLabel(err)).Inc()
+	return sub, err
+}
+
+func (m *instrumentedSubscriberV4Map) Delete(src netip.Addr) error {
+	err := m.inner.Delete(src)
+	m.metrics.Operations.WithLabelValues("delete", resultLabel(err)).Inc()
+	return err
+}
+
+func (m *instrumentedSubscriberV4Map) List() ([]*SubscriberV4, error) {
+	return m.inner.List()
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"unsafe"
+
+	"github.com/cilium/cilium/pkg/ebpf"
+)
+
+// StatsV4MapName is the pinned name of the per-CPU multicast forwarding
+// counter map.
+const StatsV4MapName = "cilium_mcast_stats_v4"
+
+// GroupStats are the datapath-maintained forwarding counters for a single
+// multicast group, summed across all CPUs.
+type GroupStats struct {
+	Packets      uint64
+	Bytes        uint64
+	Replications uint64
+}
+
+// statsV4Val is the per-CPU value of a StatsV4Map entry, updated by the
+// datapath replication code for every packet it forwards to a group.
+type statsV4Val struct {
+	Packets      uint64 `align:"packets"`
+	Bytes        uint64 `align:"bytes"`
+	Replications uint64 `align:"replications"`
+}
+
+// StatsV4Map is a per-CPU BPF hash map, keyed by GroupV4Key, tracking
+// packets/bytes/replications forwarded to each IPv4 multicast group.
+type StatsV4Map struct {
+	*ebpf.Map
+}
+
+// NewStatsV4Map creates a new StatsV4Map.
+func NewStatsV4Map(logger *slog.Logger, name string) *StatsV4Map {
+	m := ebpf.NewMap(logger, &ebpf.MapSpec{
+		Name:       name,
+		Type:       ebpf.PerCPUHash,
+		KeySize:    uint32(unsafe.Sizeof(GroupV4Key{})),
+		ValueSize:  uint32(unsafe.Sizeof(statsV4Val{})),
+		MaxEntries: uint32(MaxGroups),
+		Pinning:    ebpf.PinByName,
+	})
+
+	return &StatsV4Map{m}
+}
+
+// Stats sums the per-CPU forwarding counters for group across all CPUs. A
+// group with no recorded activity yet (or that does not exist) returns a
+// zero GroupStats rather than an error.
+func (m *StatsV4Map) Stats(group netip.Addr) (GroupStats, error) {
+	key, err := NewGroupV4KeyFromNetIPAddr(group)
+	if err != nil {
+		return GroupStats{}, err
+	}
+
+	var perCPU []statsV4Val
+	err = m.Map.Lookup(key, &perCPU)
+	if errors.Is(err, ebpf.ErrKeyNotExist) {
+		return GroupStats{}, nil
+	}
+	if err != nil {
+		return GroupStats{}, fmt.Errorf("failed to look up multicast stats for group %s: %w", group.String(), err)
+	}
+
+	var out GroupStats
+	for _, v := range perCPU {
+		out.Packets += v.Packets
+		out.Bytes += v.Bytes
+		out.Replications += v.Replications
+	}
+
+	return out, nil
+}
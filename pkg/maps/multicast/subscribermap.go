@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"log/slog"
 	"net/netip"
+	"slices"
+	"sync"
 	"unsafe"
 
 	ciliumebpf "github.com/cilium/ebpf"
@@ -42,8 +44,26 @@ type GroupV4Map interface {
 	Insert(multicastAddr netip.Addr) error
 	Delete(multicastAddr netip.Addr) error
 	List() ([]netip.Addr, error)
+
+	// OnChange registers a hook which is invoked synchronously whenever
+	// Insert or Delete succeeds. It lets a Watcher learn about
+	// control-plane driven changes without waiting for its next
+	// reconciliation tick.
+	OnChange(hook GroupHookFunc)
+
+	// Reconcile converges the map's groups and subscribers to the
+	// desired state using the minimum number of batch operations.
+	Reconcile(desired map[netip.Addr][]*SubscriberV4) error
+
+	// Stats returns the datapath-maintained forwarding counters for
+	// group.
+	Stats(group netip.Addr) (GroupStats, error)
 }
 
+// GroupHookFunc is invoked by a GroupV4Map implementation when a multicast
+// group is added or removed.
+type GroupHookFunc func(op Op, group netip.Addr)
+
 // GroupV4OuterMap outer map keyed by GroupV4Key multicast group
 // addresses.
 type GroupV4OuterMap struct {
@@ -52,6 +72,39 @@ type GroupV4OuterMap struct {
 	// batchLookupSupported indicates if the kernel supports batch lookup.
 	batchLookupSupported bool
 	logger               *slog.Logger
+
+	hooksMu sync.Mutex
+	hooks   []GroupHookFunc
+
+	// stats is nil if the stats map was not created, e.g. because the
+	// agent was built without it wired up; Stats then returns a zero
+	// GroupStats.
+	stats *StatsV4Map
+}
+
+// Stats implements GroupV4Map.
+func (m *GroupV4OuterMap) Stats(group netip.Addr) (GroupStats, error) {
+	if m.stats == nil {
+		return GroupStats{}, nil
+	}
+	return m.stats.Stats(group)
+}
+
+// OnChange implements GroupV4Map.
+func (m *GroupV4OuterMap) OnChange(hook GroupHookFunc) {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+	m.hooks = append(m.hooks, hook)
+}
+
+func (m *GroupV4OuterMap) notify(op Op, group netip.Addr) {
+	m.hooksMu.Lock()
+	hooks := slices.Clone(m.hooks)
+	m.hooksMu.Unlock()
+
+	for _, hook := range hooks {
+		hook(op, group)
+	}
 }
 
 func NewGroupV4OuterMap(logger *slog.Logger, name string) *GroupV4OuterMap {
@@ -109,6 +162,7 @@ func NewGroupV4Map(in ParamsIn) ParamsOut {
 	out.NodeDefines["ENABLE_MULTICAST"] = "1"
 
 	groupMap := NewGroupV4OuterMap(in.Logger, GroupOuter4MapName)
+	groupMap.stats = NewStatsV4Map(in.Logger, StatsV4MapName)
 
 	out.MapOut = bpf.NewMapOut((GroupV4Map(groupMap)))
 
@@ -119,9 +173,10 @@ func NewGroupV4Map(in ParamsIn) ParamsOut {
 				return err
 			}
 			groupMap.batchLookupSupported = haveBatchLookupSupport[GroupV4Key, GroupV4Val](groupMap.Map)
-			return nil
+			return groupMap.stats.OpenOrCreate()
 		},
 		OnStop: func(cell.HookContext) error {
+			groupMap.stats.Close()
 			return groupMap.Close()
 		},
 	})
@@ -129,7 +184,7 @@ func NewGroupV4Map(in ParamsIn) ParamsOut {
 	return out
 }
 
-func (m GroupV4OuterMap) Insert(group netip.Addr) error {
+func (m *GroupV4OuterMap) Insert(group netip.Addr) error {
 	key, err := NewGroupV4KeyFromNetIPAddr(group)
 	if err != nil {
 		return err
@@ -150,10 +205,12 @@ func (m GroupV4OuterMap) Insert(group netip.Addr) error {
 		return fmt.Errorf("failed to create new multicast group entry: %w", err)
 	}
 
+	m.notify(OpAdd, group)
+
 	return nil
 }
 
-func (m GroupV4OuterMap) Lookup(group netip.Addr) (SubscriberV4Map, error) {
+func (m *GroupV4OuterMap) Lookup(group netip.Addr) (SubscriberV4Map, error) {
 	var val GroupV4Val
 
 	key, err := NewGroupV4KeyFromNetIPAddr(group)
@@ -178,17 +235,23 @@ func (m GroupV4OuterMap) Lookup(group netip.Addr) (SubscriberV4Map, error) {
 	return &SubscriberV4InnerMap{subMap}, nil
 }
 
-func (m GroupV4OuterMap) Delete(group netip.Addr) error {
+func (m *GroupV4OuterMap) Delete(group netip.Addr) error {
 	key, err := NewGroupV4KeyFromNetIPAddr(group)
 	if err != nil {
 		return err
 	}
-	return m.Map.Delete(key)
+	if err := m.Map.Delete(key); err != nil {
+		return err
+	}
+
+	m.notify(OpDelete, group)
+
+	return nil
 }
 
 // List returns a list of all multicast groups in the map. Batch lookup is used to get the groups if supported.
 // Batch lookup is supported in kernel version 5.19 and later for map.HashOfMaps
-func (m GroupV4OuterMap) List() ([]netip.Addr, error) {
+func (m *GroupV4OuterMap) List() ([]netip.Addr, error) {
 	if m.batchLookupSupported {
 		return m.ListBatch()
 	}
@@ -196,7 +259,7 @@ func (m GroupV4OuterMap) List() ([]netip.Addr, error) {
 }
 
 // ListIterator is a iterator version of List. It is used when the map does not support batch lookup.
-func (m GroupV4OuterMap) ListIterator() ([]netip.Addr, error) {
+func (m *GroupV4OuterMap) ListIterator() ([]netip.Addr, error) {
 	var (
 		key GroupV4Key
 		val GroupV4Val
@@ -216,7 +279,7 @@ func (m GroupV4OuterMap) ListIterator() ([]netip.Addr, error) {
 }
 
 // ListBatch is a batched version of List. It is used when the map supports batch lookup.
-func (m GroupV4OuterMap) ListBatch() ([]netip.Addr, error) {
+func (m *GroupV4OuterMap) ListBatch() ([]netip.Addr, error) {
 	var (
 		keys = make([]GroupV4Key, MaxGroups)
 		vals = make([]GroupV4Val, MaxGroups)
@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package multicast
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/cilium/hive/cell"
+)
+
+// Op describes the kind of change an Event represents.
+type Op int
+
+const (
+	// OpAdd indicates a group or subscriber was added.
+	OpAdd Op = iota
+	// OpDelete indicates a group or subscriber was removed.
+	OpDelete
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpAdd:
+		return "add"
+	case OpDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a single change to a multicast group or one of its
+// subscribers.
+type Event struct {
+	// GroupAddr is the multicast group the event pertains to.
+	GroupAddr netip.Addr
+	// Subscriber is set when the event describes a subscriber add/delete.
+	// It is nil when the event describes a group add/delete.
+	Subscriber *SubscriberV4
+	// Op is the kind of change that occurred.
+	Op Op
+}
+
+const (
+	// defaultReconcilePeriod is how often the Watcher diffs snapshots of
+	// GroupV4Map/SubscriberV4Map against what it last observed.
+	defaultReconcilePeriod = 5 * time.Second
+	// defaultSubscriberChanSize bounds how many unconsumed events a Watch
+	// channel may accumulate before events are dropped.
+	defaultSubscriberChanSize = 256
+)
+
+// WatcherCell provides the Watcher singleton to the hive dependency
+// injection graph.
+var WatcherCell = cell.Module(
+	"multicast-watcher",
+	"Watches multicast group/subscriber map changes and emits coalesced events",
+
+	cell.Provide(NewWatcher),
+)
+
+// WatcherParams are the parameters provided by the Hive for constructing a
+// Watcher.
+type WatcherParams struct {
+	cell.In
+
+	Lifecycle cell.Lifecycle
+	Logger    *slog.Logger
+	Groups    GroupV4Map `optional:"true"`
+}
+
+// NewWatcher creates a Watcher and registers its run loop with the hive
+// lifecycle. If no GroupV4Map was provided (multicast disabled), a Watcher
+// is still returned but never emits events.
+func NewWatcher(p WatcherParams) *Watcher {
+	w := &Watcher{
+		logger: p.Logger,
+		groups: p.Groups,
+		period: defaultReconcilePeriod,
+		subs:   make(map[chan Event]*subscription),
+	}
+
+	if p.Groups != nil {
+		p.Groups.OnChange(w.onGroupChange)
+	}
+
+	p.Lifecycle.Append(cell.Hook{
+		OnStart: func(ctx cell.HookContext) error {
+			if w.groups == nil {
+				return nil
+			}
+			runCtx, cancel := context.WithCancel(context.Background())
+			w.cancel = cancel
+			go w.run(runCtx)
+			return nil
+		},
+		OnStop: func(cell.HookContext) error {
+			if w.cancel != nil {
+				w.cancel()
+			}
+			return nil
+		},
+	})
+
+	return w
+}
+
+// subscription tracks per-subscriber backpressure state.
+type subscription struct {
+	ch      chan Event
+	dropped uint64
+}
+
+// Watcher reconciles snapshots of a GroupV4Map and its subscriber maps into
+// a stream of Events, so that consumers (agent controllers, Hubble, the CNI
+// plugin) do not each need to re-implement iteration and diffing over
+// List().
+type Watcher struct {
+	logger *slog.Logger
+	groups GroupV4Map
+	period time.Duration
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	subs map[chan Event]*subscription
+
+	// lastGroups/lastSubscribers hold the last observed snapshot, used to
+	// compute the diff on the following tick.
+	lastGroups      map[netip.Addr]struct{}
+	lastSubscribers map[netip.Addr]map[netip.Addr]struct{}
+}
+
+// Watch returns a channel of Events. The channel is closed when ctx is
+// canceled. If the consumer falls behind, the oldest buffered event is
+// dropped to make room for the newest one; Watcher tracks how many events
+// were dropped per subscriber for observability.
+func (w *Watcher) Watch(ctx context.Context) <-chan Event {
+	ch := make(chan Event, defaultSubscriberChanSize)
+	sub := &subscription{ch: ch}
+
+	w.mu.Lock()
+	w.subs[ch] = sub
+	w.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		w.mu.Lock()
+		delete(w.subs, ch)
+		w.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// onGroupChange is registered with the underlying GroupV4Map as a
+// GroupHookFunc, letting control-plane driven Insert/Delete calls reach
+// subscribers immediately instead of waiting for the next reconcile tick.
+func (w *Watcher) onGroupChange(op Op, group netip.Addr) {
+	w.emit(Event{GroupAddr: group, Op: op})
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.reconcile()
+		}
+	}
+}
+
+// reconcile diffs a fresh snapshot of groups and subscribers against the
+// last observed one, emitting coalesced Events for anything that changed.
+func (w *Watcher) reconcile() {
+	groups, err := w.groups.List()
+	if err != nil {
+		w.logger.Warn("multicast watcher failed to list groups", "error", err)
+		return
+	}
+
+	curGroups := make(map[netip.Addr]struct{}, len(groups))
+	curSubscribers := make(map[netip.Addr]map[netip.Addr]struct{}, len(groups))
+
+	for _, group := range groups {
+		curGroups[group] = struct{}{}
+
+		subMap, err := w.groups.Lookup(group)
+		if err != nil {
+			w.logger.Warn("multicast watcher failed to look up group", "group", group, "error", err)
+			// Carry forward the last observed subscriber set for this
+			// group rather than dropping it, so a transient failure
+			// doesn't make the next successful tick see prevAddrs == nil
+			// and re-emit spurious OpAdd events for every subscriber
+			// still present.
+			if prev, ok := w.lastSubscribers[group]; ok {
+				curSubscribers[group] = prev
+			}
+			continue
+		}
+
+		subscribers, err := subMap.List()
+		if err != nil {
+			w.logger.Warn("multicast watcher failed to list subscribers", "group", group, "error", err)
+			if prev, ok := w.lastSubscribers[group]; ok {
+				curSubscribers[group] = prev
+			}
+			continue
+		}
+
+		addrs := make(map[netip.Addr]struct{}, len(subscribers))
+		for _, sub := range subscribers {
+			addrs[sub.SAddr] = struct{}{}
+		}
+		curSubscribers[group] = addrs
+
+		if _, existed := w.lastGroups[group]; !existed {
+			w.emit(Event{GroupAddr: group, Op: OpAdd})
+		}
+
+		prevAddrs := w.lastSubscribers[group]
+		for _, sub := range subscribers {
+			if _, existed := prevAddrs[sub.SAddr]; !existed {
+				w.emit(Event{GroupAddr: group, Subscriber: sub, Op: OpAdd})
+			}
+		}
+		for addr := range prevAddrs {
+			if _, stillPresent := addrs[addr]; !stillPresent {
+				w.emit(Event{GroupAddr: group, Subscriber: &SubscriberV4{SAddr: addr}, Op: OpDelete})
+			}
+		}
+	}
+
+	for group := range w.lastGroups {
+		if _, stillPresent := curGroups[group]; !stillPresent {
+			w.emit(Event{GroupAddr: group, Op: OpDelete})
+		}
+	}
+
+	w.lastGroups = curGroups
+	w.lastSubscribers = curSubscribers
+}
+
+// emit delivers ev to every subscriber, dropping the oldest buffered event
+// for any subscriber whose channel is full.
+func (w *Watcher) emit(ev Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, sub := range w.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			select {
+			case <-sub.ch:
+				sub.dropped++
+			default:
+			}
+			select {
+			case sub.ch <- ev:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package igmp
+
+import (
+	"log/slog"
+
+	"github.com/cilium/hive/cell"
+	"github.com/spf13/pflag"
+
+	"github.com/cilium/cilium/pkg/maps/multicast"
+)
+
+// Cell provides the IGMP snooping Snooper to the hive dependency injection
+// graph. It depends on multicast.GroupV4Map and is a no-op if that
+// dependency is absent (multicast disabled) or if SnoopingInterfaces is
+// empty.
+var Cell = cell.Module(
+	"igmp-snooping",
+	"IGMPv2/v3 snooping for the BPF multicast subscriber maps",
+
+	cell.Config(defaultConfig),
+	cell.Provide(newSnooper),
+)
+
+const (
+	SnoopingInterfacesName = "igmp-snooping-interfaces"
+	UplinkInterfacesName   = "igmp-snooping-uplink-interfaces"
+	QuerierModeName        = "igmp-querier-mode"
+	SnoopingVersionName    = "igmp-snooping-version"
+)
+
+// Config configures the IGMP snooping subsystem.
+type Config struct {
+	// SnoopingInterfaces lists the interfaces to snoop IGMP traffic on.
+	// Snooping is disabled if empty.
+	SnoopingInterfaces []string `mapstructure:"igmp-snooping-interfaces"`
+
+	// UplinkInterfaces is the subset of SnoopingInterfaces that carry
+	// traffic to/from remote nodes (e.g. a tunnel device). Reports seen
+	// on these interfaces are inserted as remote subscribers; reports
+	// seen on any other snooped interface are treated as local pods.
+	UplinkInterfaces []string `mapstructure:"igmp-snooping-uplink-interfaces"`
+
+	// QuerierMode enables sending periodic General Queries on the
+	// snooped interfaces that are not UplinkInterfaces. Queries are sent
+	// regardless of whether another querier is present on the link; no
+	// querier election (RFC 3376 section 6) is performed.
+	QuerierMode bool `mapstructure:"igmp-querier-mode"`
+
+	// SnoopingVersion is the highest IGMP version to parse/generate: 2
+	// or 3.
+	SnoopingVersion int `mapstructure:"igmp-snooping-version"`
+}
+
+var defaultConfig = Config{
+	SnoopingInterfaces: nil,
+	UplinkInterfaces:   nil,
+	QuerierMode:        false,
+	SnoopingVersion:    3,
+}
+
+// Flags implements the cell.Flagger interface.
+func (def Config) Flags(flags *pflag.FlagSet) {
+	flags.StringSlice(SnoopingInterfacesName, def.SnoopingInterfaces, "Interfaces to snoop IGMP membership reports on")
+	flags.StringSlice(UplinkInterfacesName, def.UplinkInterfaces, "Subset of "+SnoopingInterfacesName+" that are remote-node uplinks rather than local pod interfaces")
+	flags.Bool(QuerierModeName, def.QuerierMode, "Send periodic IGMP General Queries on snooped non-uplink interfaces (no querier election)")
+	flags.Int(SnoopingVersionName, def.SnoopingVersion, "Highest IGMP version to snoop/query (2 or 3)")
+}
+
+// SnooperParams are the parameters provided by the Hive for constructing a
+// Snooper.
+type SnooperParams struct {
+	cell.In
+
+	Lifecycle cell.Lifecycle
+	Logger    *slog.Logger
+	Config    Config
+	Groups    multicast.GroupV4Map `optional:"true"`
+}
+
+// newSnooper constructs a Snooper and registers its run loop with the hive
+// lifecycle. It is a no-op if multicast is disabled or no interfaces are
+// configured for snooping.
+func newSnooper(p SnooperParams) *Snooper {
+	s := &Snooper{
+		logger:  p.Logger,
+		groups:  p.Groups,
+		config:  p.Config,
+		members: newMembershipTable(),
+	}
+
+	if p.Groups == nil || len(p.Config.SnoopingInterfaces) == 0 {
+		return s
+	}
+
+	p.Lifecycle.Append(cell.Hook{
+		OnStart: func(ctx cell.HookContext) error {
+			return s.start()
+		},
+		OnStop: func(cell.HookContext) error {
+			s.stop()
+			return nil
+		},
+	})
+
+	return s
+}
@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package igmp implements IGMPv2/v3 (RFC 2236, RFC 3376) snooping, used to
+// auto-populate the IPv4 multicast subscriber maps in
+// github.com/cilium/cilium/pkg/maps/multicast from observed group
+// membership reports rather than requiring subscribers to be inserted
+// programmatically.
+package igmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// Version identifies the IGMP protocol version of a parsed message.
+type Version uint8
+
+const (
+	VersionUnknown Version = iota
+	Version1
+	Version2
+	Version3
+)
+
+// messageType is the IGMP "Type" field, shared by all IGMP versions.
+type messageType uint8
+
+const (
+	typeMembershipQuery    messageType = 0x11
+	typeV1MembershipReport messageType = 0x12
+	typeV2MembershipReport messageType = 0x16
+	typeV2LeaveGroup       messageType = 0x17
+	typeV3MembershipReport messageType = 0x22
+)
+
+// recordType is the "Record Type" field of an IGMPv3 group record.
+type recordType uint8
+
+const (
+	modeIsInclude   recordType = 1
+	modeIsExclude   recordType = 2
+	changeToInclude recordType = 3
+	changeToExclude recordType = 4
+	allowNewSources recordType = 5
+	blockOldSources recordType = 6
+)
+
+// minimum on-wire sizes, per RFC 2236 section 2 and RFC 3376 section 4.
+const (
+	minMessageLen       = 8
+	v3GroupRecordMinLen = 8
+)
+
+// ipProtocolIGMP is the IPv4 "Protocol" field value for IGMP, per RFC 2236
+// section 2.
+const ipProtocolIGMP = 2
+
+// Report describes a single group membership assertion extracted from an
+// IGMPv2 or IGMPv3 packet. A Report with no Sources is a "join all sources"
+// (EXCLUDE {}) report or a plain IGMPv2 report/leave.
+type Report struct {
+	Version Version
+	Group   netip.Addr
+	Sources []netip.Addr
+	// Leave is true for an IGMPv2 Leave Group message or an IGMPv3
+	// CHANGE_TO_INCLUDE record with an empty source list (TO_IN({})),
+	// meaning the subscriber should be removed rather than inserted. No
+	// other IGMPv3 record type sets it.
+	Leave bool
+}
+
+// ParseMessage parses the IGMP payload of an IPv4 packet (i.e. everything
+// after the IPv4 header) and returns the Reports it asserts. Membership
+// Query messages are not reports and yield no Reports, but are returned via
+// ParseQuery instead.
+func ParseMessage(b []byte) ([]Report, error) {
+	if len(b) < minMessageLen {
+		return nil, fmt.Errorf("igmp: message too short: %d bytes", len(b))
+	}
+
+	switch messageType(b[0]) {
+	case typeV2MembershipReport:
+		group, ok := netip.AddrFromSlice(b[4:8])
+		if !ok {
+			return nil, fmt.Errorf("igmp: invalid v2 report group address")
+		}
+		return []Report{{Version: Version2, Group: group}}, nil
+
+	case typeV2LeaveGroup:
+		group, ok := netip.AddrFromSlice(b[4:8])
+		if !ok {
+			return nil, fmt.Errorf("igmp: invalid v2 leave group address")
+		}
+		return []Report{{Version: Version2, Group: group, Leave: true}}, nil
+
+	case typeV1MembershipReport:
+		group, ok := netip.AddrFromSlice(b[4:8])
+		if !ok {
+			return nil, fmt.Errorf("igmp: invalid v1 report group address")
+		}
+		return []Report{{Version: Version1, Group: group}}, nil
+
+	case typeV3MembershipReport:
+		return parseV3Report(b)
+
+	default:
+		return nil, nil
+	}
+}
+
+// parseV3Report parses the group records of an IGMPv3 Membership Report,
+// per RFC 3376 section 4.2.
+func parseV3Report(b []byte) ([]Report, error) {
+	// header: type(1) reserved(1) checksum(2) reserved(2) num-records(2)
+	if len(b) < 8 {
+		return nil, fmt.Errorf("igmp: v3 report too short")
+	}
+	numRecords := binary.BigEndian.Uint16(b[6:8])
+
+	var (
+		out = make([]Report, 0, numRecords)
+		off = 8
+	)
+
+	for i := 0; i < int(numRecords); i++ {
+		if len(b)-off < v3GroupRecordMinLen {
+			return nil, fmt.Errorf("igmp: v3 report truncated at record %d", i)
+		}
+
+		rType := recordType(b[off])
+		auxDataLen := int(b[off+1])
+		numSources := int(binary.BigEndian.Uint16(b[off+2 : off+4]))
+		group, ok := netip.AddrFromSlice(b[off+4 : off+8])
+		if !ok {
+			return nil, fmt.Errorf("igmp: v3 report record %d has invalid group address", i)
+		}
+
+		srcOff := off + 8
+		srcEnd := srcOff + numSources*4
+		if len(b) < srcEnd {
+			return nil, fmt.Errorf("igmp: v3 report record %d source list truncated", i)
+		}
+
+		sources := make([]netip.Addr, 0, numSources)
+		for s := 0; s < numSources; s++ {
+			addr, ok := netip.AddrFromSlice(b[srcOff+s*4 : srcOff+s*4+4])
+			if !ok {
+				return nil, fmt.Errorf("igmp: v3 report record %d has invalid source address", i)
+			}
+			sources = append(sources, addr)
+		}
+
+		// Per RFC 3376 section 5.1, only TO_IN({}) (CHANGE_TO_INCLUDE_MODE
+		// with an empty source list) signals a leave. BLOCK_OLD_SOURCES
+		// with an empty source list blocks nothing and is a no-op, not a
+		// leave.
+		leave := rType == changeToInclude && numSources == 0
+
+		out = append(out, Report{
+			Version: Version3,
+			Group:   group,
+			Sources: sources,
+			Leave:   leave,
+		})
+
+		off = srcEnd + auxDataLen*4
+	}
+
+	return out, nil
+}
+
+// Query describes a parsed IGMP Membership Query, used to derive the Group
+// Membership Interval for the membership timer table.
+type Query struct {
+	Version Version
+	// MaxRespTime is the Max Resp Time/Code in tenths of a second.
+	MaxRespTime uint16
+	// QQIC is the Querier's Query Interval Code, in seconds. Zero for
+	// IGMPv2 queries, which carry no QQIC.
+	QQIC uint8
+	// QRV is the Querier's Robustness Variable. Zero for IGMPv2 queries.
+	QRV uint8
+}
+
+// BuildQuery constructs the IGMP payload for a General Query (group
+// 0.0.0.0), for use by a querier. version must be Version2 or Version3;
+// maxRespTime is the Max Resp Code in tenths of a second.
+func BuildQuery(version Version, maxRespTime uint8) ([]byte, error) {
+	switch version {
+	case Version2:
+		b := make([]byte, minMessageLen)
+		b[0] = byte(typeMembershipQuery)
+		b[1] = maxRespTime
+		binary.BigEndian.PutUint16(b[2:4], igmpChecksum(b))
+		return b, nil
+
+	case Version3:
+		// RFC 3376 section 4.1: type(1) max-resp-code(1) checksum(2)
+		// group(4) resv/s/qrv(1) qqic(1) num-srcs(2).
+		b := make([]byte, 12)
+		b[0] = byte(typeMembershipQuery)
+		b[1] = maxRespTime
+		binary.BigEndian.PutUint16(b[10:12], 0)
+		binary.BigEndian.PutUint16(b[2:4], igmpChecksum(b))
+		return b, nil
+
+	default:
+		return nil, fmt.Errorf("igmp: cannot build a query for version %d", version)
+	}
+}
+
+// igmpChecksum computes the IGMP checksum (the internet checksum, per RFC
+// 1071) of b with the checksum field itself (b[2:4]) treated as zero.
+func igmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		if i == 2 {
+			continue
+		}
+		sum += uint32(binary.BigEndian.Uint16(b[i : i+2]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+// ParseQuery parses an IGMP Membership Query message.
+func ParseQuery(b []byte) (*Query, error) {
+	if len(b) < minMessageLen || messageType(b[0]) != typeMembershipQuery {
+		return nil, fmt.Errorf("igmp: not a membership query")
+	}
+
+	q := &Query{MaxRespTime: uint16(b[1])}
+
+	// RFC 3376 section 7.1: a Query is IGMPv3 if it is at least 12 bytes,
+	// otherwise it is an IGMPv1/v2 query.
+	if len(b) >= 12 {
+		q.Version = Version3
+		q.QRV = b[8] & 0x07
+		q.QQIC = b[9]
+	} else if q.MaxRespTime == 0 {
+		q.Version = Version1
+	} else {
+		q.Version = Version2
+	}
+
+	return q, nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package igmp
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQueryInterval is used to compute the Group Membership
+	// Interval until a Query is observed on the wire, per RFC 3376
+	// section 8.2.
+	defaultQueryInterval = 125 * time.Second
+	// defaultRobustness is the default Querier's Robustness Variable, per
+	// RFC 3376 section 8.1.
+	defaultRobustness = 2
+	// defaultQueryResponseInterval is the default Max Response Time, per
+	// RFC 3376 section 8.3.
+	defaultQueryResponseInterval = 10 * time.Second
+)
+
+// memberKey identifies a single {group, source-if-any} membership on a
+// given interface.
+type memberKey struct {
+	ifindex int
+	group   netip.Addr
+	source  netip.Addr
+}
+
+// membershipTable tracks, per interface, the Group Membership Interval
+// timer for each observed {group, source} pair, so that a subscriber is
+// expired if no refreshing report arrives in time.
+//
+// Per RFC 3376 section 8.4, the Group Membership Interval is
+// (Robustness Variable * Query Interval) + Query Response Interval. It is
+// recomputed from the most recently observed Query on each interface.
+type membershipTable struct {
+	mu sync.Mutex
+
+	queryInterval map[int]time.Duration
+	robustness    map[int]int
+	responseTime  map[int]time.Duration
+	expiry        map[memberKey]time.Time
+}
+
+func newMembershipTable() *membershipTable {
+	return &membershipTable{
+		queryInterval: make(map[int]time.Duration),
+		robustness:    make(map[int]int),
+		responseTime:  make(map[int]time.Duration),
+		expiry:        make(map[memberKey]time.Time),
+	}
+}
+
+// observeQuery updates the Group Membership Interval parameters learned
+// from a Query seen on ifindex.
+func (t *membershipTable) observeQuery(ifindex int, q *Query) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if q.Version == Version3 && q.QQIC > 0 {
+		t.queryInterval[ifindex] = time.Duration(q.QQIC) * time.Second
+	}
+	if q.Version == Version3 && q.QRV > 0 {
+		t.robustness[ifindex] = int(q.QRV)
+	}
+	if q.MaxRespTime > 0 {
+		t.responseTime[ifindex] = time.Duration(q.MaxRespTime) * 100 * time.Millisecond
+	}
+}
+
+// groupMembershipInterval returns the current Group Membership Interval for
+// ifindex, falling back to RFC 3376 defaults for parameters that have not
+// yet been learned from a Query.
+func (t *membershipTable) groupMembershipInterval(ifindex int) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	qi, ok := t.queryInterval[ifindex]
+	if !ok {
+		qi = defaultQueryInterval
+	}
+	rv, ok := t.robustness[ifindex]
+	if !ok {
+		rv = defaultRobustness
+	}
+	rt, ok := t.responseTime[ifindex]
+	if !ok {
+		rt = defaultQueryResponseInterval
+	}
+
+	return time.Duration(rv)*qi + rt
+}
+
+// refresh (re)starts the expiry timer for a {group, source} membership on
+// ifindex, returning true if this is a newly observed membership.
+func (t *membershipTable) refresh(ifindex int, group, source netip.Addr, now time.Time) bool {
+	key := memberKey{ifindex: ifindex, group: group, source: source}
+	interval := t.groupMembershipInterval(ifindex)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, existed := t.expiry[key]
+	t.expiry[key] = now.Add(interval)
+	return !existed
+}
+
+// remove deletes a {group, source} membership, e.g. on an explicit Leave.
+func (t *membershipTable) remove(ifindex int, group, source netip.Addr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.expiry, memberKey{ifindex: ifindex, group: group, source: source})
+}
+
+// expired returns every {group, source} membership whose timer has lapsed
+// as of now, removing them from the table.
+func (t *membershipTable) expired(now time.Time) []memberKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []memberKey
+	for key, deadline := range t.expiry {
+		if now.After(deadline) {
+			out = append(out, key)
+			delete(t.expiry, key)
+		}
+	}
+	return out
+}
@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package igmp
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cilium/cilium/pkg/maps/multicast"
+)
+
+// reconcileInterval bounds how often expired memberships are swept from the
+// membership table between incoming reports.
+const reconcileInterval = time.Second
+
+// querierInterval is how often a General Query is sent on snooped
+// interfaces when Config.QuerierMode is enabled, per the default Query
+// Interval of RFC 3376 section 8.2.
+const querierInterval = 125 * time.Second
+
+// querierMaxRespTime is the Max Resp Code advertised in General Queries, in
+// tenths of a second, per the default Max Response Time of RFC 3376 section
+// 8.3.
+const querierMaxRespTime = 100
+
+// packetSource is the subset of a raw IGMP listener that Snooper depends
+// on, so that the socket implementation can be swapped out in environments
+// where attaching to a raw socket isn't possible (e.g. TC/XDP based
+// capture).
+type packetSource interface {
+	// ReadFrom blocks until an IGMP packet is received and returns its
+	// payload (the IGMP message, without the IPv4 header) along with the
+	// ifindex it was received on.
+	ReadFrom() (payload []byte, ifindex int, err error)
+	// WriteTo sends an IGMP payload (e.g. a General Query built by
+	// BuildQuery) out the interface, wrapped in an IPv4 header addressed
+	// to the all-systems multicast group. Used only in querier mode.
+	WriteTo(payload []byte) error
+	Close() error
+}
+
+// Snooper implements IGMPv2/v3 snooping: it listens for Membership
+// Reports/Leaves on a set of configured interfaces and reconciles observed
+// group membership into a multicast.GroupV4Map, tagging subscribers as
+// local or remote depending on which interface they were learned from.
+type Snooper struct {
+	logger *slog.Logger
+	config Config
+	groups multicast.GroupV4Map
+
+	members *membershipTable
+
+	mu        sync.Mutex
+	listeners map[string]packetSource
+	ifindex   map[string]int
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+
+	// newListener opens a packetSource for the given interface. It is a
+	// field (rather than a free function call) so tests can substitute a
+	// fake listener.
+	newListener func(iface string) (packetSource, error)
+}
+
+func (s *Snooper) isRemote(ifaceName string) bool {
+	return slices.Contains(s.config.UplinkInterfaces, ifaceName)
+}
+
+// start opens a packetSource for every configured interface and begins
+// snooping. If any interface fails to start, nothing is left half-running:
+// whatever did start is torn back down and the error is returned so the
+// hive cell fails loudly instead of silently operating on a subset of
+// interfaces (or none at all).
+func (s *Snooper) start() error {
+	if s.newListener == nil {
+		s.newListener = newRawIGMPListener
+	}
+
+	s.mu.Lock()
+	s.listeners = make(map[string]packetSource, len(s.config.SnoopingInterfaces))
+	s.stopCh = make(chan struct{})
+
+	var errs []error
+	for _, iface := range s.config.SnoopingInterfaces {
+		l, err := s.newListener(iface)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("interface %s: %w", iface, err))
+			continue
+		}
+		s.listeners[iface] = l
+
+		s.wg.Add(1)
+		go s.listen(iface, l)
+	}
+
+	if len(errs) > 0 {
+		close(s.stopCh)
+		for _, l := range s.listeners {
+			l.Close()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+		return fmt.Errorf("failed to start IGMP snooping: %w", errors.Join(errs...))
+	}
+
+	s.wg.Add(1)
+	go s.expireLoop()
+
+	if s.config.QuerierMode {
+		s.wg.Add(1)
+		go s.querierLoop()
+	}
+
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *Snooper) stop() {
+	s.mu.Lock()
+	close(s.stopCh)
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Snooper) listen(iface string, l packetSource) {
+	defer s.wg.Done()
+
+	for {
+		payload, ifindex, err := l.ReadFrom()
+		if err != nil {
+			select {
+			case <-s.stopCh:
+				return
+			default:
+				s.logger.Warn("IGMP snooping socket read failed", "interface", iface, "error", err)
+				return
+			}
+		}
+
+		s.handlePacket(iface, ifindex, payload)
+	}
+}
+
+func (s *Snooper) handlePacket(iface string, ifindex int, payload []byte) {
+	if q, err := ParseQuery(payload); err == nil {
+		s.members.observeQuery(ifindex, q)
+		return
+	}
+
+	reports, err := ParseMessage(payload)
+	if err != nil {
+		s.logger.Debug("failed to parse IGMP message", "interface", iface, "error", err)
+		return
+	}
+
+	if s.config.SnoopingVersion < 3 {
+		reports = slices.DeleteFunc(reports, func(r Report) bool {
+			if r.Version == Version3 {
+				s.logger.Debug("ignoring IGMPv3 report, snooping is capped to v2", "interface", iface, "group", r.Group)
+				return true
+			}
+			return false
+		})
+	}
+
+	remote := s.isRemote(iface)
+	now := time.Now()
+
+	for _, r := range reports {
+		if r.Leave {
+			s.leave(ifindex, remote, r, now)
+			continue
+		}
+		s.join(ifindex, remote, r, now)
+	}
+}
+
+func (s *Snooper) join(ifindex int, remote bool, r Report, now time.Time) {
+	sources := r.Sources
+	if len(sources) == 0 {
+		// IGMPv2 report, or an IGMPv3 EXCLUDE {} report: membership in
+		// the group as a whole, with no specific source.
+		sources = []netip.Addr{netip.IPv4Unspecified()}
+	}
+
+	if err := s.ensureGroup(r.Group); err != nil {
+		s.logger.Warn("failed to ensure multicast group", "group", r.Group, "error", err)
+		return
+	}
+
+	subMap, err := s.groups.Lookup(r.Group)
+	if err != nil {
+		s.logger.Warn("failed to look up multicast group", "group", r.Group, "error", err)
+		return
+	}
+
+	for _, source := range sources {
+		isNew := s.members.refresh(ifindex, r.Group, source, now)
+		if !isNew {
+			continue
+		}
+		err := subMap.Insert(&multicast.SubscriberV4{
+			SAddr:    source,
+			Ifindex:  uint32(ifindex),
+			IsRemote: remote,
+		})
+		if err != nil {
+			s.logger.Warn("failed to insert multicast subscriber", "group", r.Group, "source", source, "error", err)
+		}
+	}
+}
+
+func (s *Snooper) leave(ifindex int, remote bool, r Report, now time.Time) {
+	sources := r.Sources
+	if len(sources) == 0 {
+		sources = []netip.Addr{netip.IPv4Unspecified()}
+	}
+
+	subMap, err := s.groups.Lookup(r.Group)
+	if err != nil {
+		// Nothing to remove if the group doesn't exist.
+		return
+	}
+
+	for _, source := range sources {
+		s.members.remove(ifindex, r.Group, source)
+		if err := subMap.Delete(source); err != nil {
+			s.logger.Debug("failed to delete multicast subscriber", "group", r.Group, "source", source, "error", err)
+		}
+	}
+}
+
+// ensureGroup creates the group in the outer map if it does not already
+// exist.
+func (s *Snooper) ensureGroup(group netip.Addr) error {
+	_, err := s.groups.Lookup(group)
+	if err == nil {
+		return nil
+	}
+	if err := s.groups.Insert(group); err != nil {
+		return fmt.Errorf("failed to insert multicast group: %w", err)
+	}
+	return nil
+}
+
+// expireLoop periodically removes subscribers whose membership timer has
+// lapsed without a refreshing report, per RFC 3376 section 8.4.
+func (s *Snooper) expireLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case now := <-ticker.C:
+			for _, key := range s.members.expired(now) {
+				subMap, err := s.groups.Lookup(key.group)
+				if err != nil {
+					continue
+				}
+				if err := subMap.Delete(key.source); err != nil {
+					s.logger.Debug("failed to delete expired multicast subscriber", "group", key.group, "source", key.source, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// querierLoop periodically sends a General Query on every snooped interface
+// that isn't a remote-node uplink, per RFC 3376 section 6. It only runs
+// when Config.QuerierMode is enabled.
+func (s *Snooper) querierLoop() {
+	defer s.wg.Done()
+
+	version := Version2
+	if s.config.SnoopingVersion >= 3 {
+		version = Version3
+	}
+
+	query, err := BuildQuery(version, querierMaxRespTime)
+	if err != nil {
+		s.logger.Warn("failed to build IGMP query, querier mode disabled", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(querierInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			for iface, l := range s.listeners {
+				if s.isRemote(iface) {
+					continue
+				}
+				if err := l.WriteTo(query); err != nil {
+					s.logger.Warn("failed to send IGMP query", "interface", iface, "error", err)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package igmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// allSystemsMAC is the destination Ethernet address for the 224.0.0.1
+// all-systems multicast group, derived per RFC 1112 section 6.4
+// (01:00:5e + low 23 bits of the group address).
+var allSystemsMAC = [8]byte{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01}
+
+// rawIGMPListener is the production packetSource: an AF_PACKET socket bound
+// to a single interface and filtered to ETH_P_IP frames, from which IGMP
+// (IP protocol 2) payloads are extracted.
+type rawIGMPListener struct {
+	fd      int
+	ifindex int
+	srcIP   [4]byte
+}
+
+// newRawIGMPListener opens a raw AF_PACKET socket bound to iface, ready to
+// receive IGMP Membership Reports/Leaves/Queries.
+func newRawIGMPListener(iface string) (packetSource, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve interface %s: %w", iface, err)
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_IP)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AF_PACKET socket on %s: %w", iface, err)
+	}
+
+	addr := unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_IP),
+		Ifindex:  ifi.Index,
+	}
+	if err := unix.Bind(fd, &addr); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind AF_PACKET socket to %s: %w", iface, err)
+	}
+
+	var srcIP [4]byte
+	if addrs, err := ifi.Addrs(); err == nil {
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if v4 := ipNet.IP.To4(); v4 != nil {
+				copy(srcIP[:], v4)
+				break
+			}
+		}
+	}
+
+	return &rawIGMPListener{fd: fd, ifindex: ifi.Index, srcIP: srcIP}, nil
+}
+
+// ReadFrom reads IPv4 frames off the socket until it finds one carrying
+// IGMP, then returns the IGMP payload with the IPv4 header stripped.
+func (l *rawIGMPListener) ReadFrom() ([]byte, int, error) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := unix.Recvfrom(l.fd, buf, 0)
+		if err != nil {
+			return nil, l.ifindex, fmt.Errorf("failed to read from AF_PACKET socket: %w", err)
+		}
+		if n < 20 {
+			continue
+		}
+
+		ihl := int(buf[0]&0x0f) * 4
+		if ihl < 20 || n < ihl {
+			continue
+		}
+		if protocol := buf[9]; protocol != ipProtocolIGMP {
+			continue
+		}
+
+		// Bound the payload by the IPv4 Total Length rather than n, so
+		// that link-layer padding on short frames is not returned as
+		// part of the IGMP message.
+		totalLen := int(binary.BigEndian.Uint16(buf[2:4]))
+		if totalLen < ihl || totalLen > n {
+			continue
+		}
+
+		payload := make([]byte, totalLen-ihl)
+		copy(payload, buf[ihl:totalLen])
+		return payload, l.ifindex, nil
+	}
+}
+
+// WriteTo wraps payload (an IGMP message built by BuildQuery) in an IPv4
+// header addressed to 224.0.0.1 (all-systems) and sends it as a single
+// Ethernet frame addressed to the corresponding multicast MAC.
+func (l *rawIGMPListener) WriteTo(payload []byte) error {
+	const ipHeaderLen = 24 // 20 bytes + 4-byte Router Alert option, per RFC 2113
+
+	pkt := make([]byte, ipHeaderLen+len(payload))
+	pkt[0] = 0x46 // version 4, IHL 6 (24 bytes)
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+	pkt[8] = 1 // TTL
+	pkt[9] = ipProtocolIGMP
+	copy(pkt[12:16], l.srcIP[:])
+	copy(pkt[16:20], []byte{224, 0, 0, 1})
+	// Router Alert option (RFC 2113), required for IGMP per RFC 3376 section 4.
+	pkt[20], pkt[21], pkt[22], pkt[23] = 0x94, 0x04, 0x00, 0x00
+	binary.BigEndian.PutUint16(pkt[10:12], ipChecksum(pkt[:ipHeaderLen]))
+	copy(pkt[ipHeaderLen:], payload)
+
+	addr := unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_IP),
+		Ifindex:  l.ifindex,
+		Halen:    6,
+	}
+	copy(addr.Addr[:6], allSystemsMAC[:6])
+
+	if err := unix.Sendto(l.fd, pkt, 0, &addr); err != nil {
+		return fmt.Errorf("failed to send IGMP query: %w", err)
+	}
+	return nil
+}
+
+// ipChecksum computes the IPv4 header checksum (the internet checksum, per
+// RFC 1071) of hdr with the checksum field itself (hdr[10:12]) treated as
+// zero.
+func ipChecksum(hdr []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(hdr); i += 2 {
+		if i == 10 {
+			continue
+		}
+		sum += uint32(binary.BigEndian.Uint16(hdr[i : i+2]))
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+func (l *rawIGMPListener) Close() error {
+	return unix.Close(l.fd)
+}
+
+// htons converts a uint16 from host to network byte order.
+func htons(v uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return binary.LittleEndian.Uint16(b)
+}
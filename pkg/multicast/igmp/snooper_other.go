@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+//go:build !linux
+
+package igmp
+
+import "fmt"
+
+// newRawIGMPListener fails immediately on non-Linux platforms: raw IGMP
+// capture is only implemented via AF_PACKET, which is Linux-specific. This
+// makes Snooper.start (and therefore the hive cell) fail loudly rather than
+// silently running with no working listeners.
+func newRawIGMPListener(iface string) (packetSource, error) {
+	return nil, fmt.Errorf("igmp: raw socket snooping is only supported on linux (interface %s)", iface)
+}